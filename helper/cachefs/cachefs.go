@@ -0,0 +1,433 @@
+// Package cachefs wraps a slow backing billy.Filesystem (e.g. an
+// S3/HTTP-backed implementation) with a fast local billy.Filesystem
+// cache (e.g. memfs, or osfs on tmpfs), in the spirit of afero's
+// cacheOnReadFs.
+package cachefs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// CacheMode governs how a write made through a Cache is propagated to
+// backing and cache.
+type CacheMode int
+
+const (
+	// ModePassthrough sends writes straight to backing. Any existing
+	// cache copy is left in place, but its entry is dropped, so the next
+	// read through this Cache copies the file in from backing again.
+	ModePassthrough CacheMode = iota
+	// ModeWriteThrough writes to backing and cache synchronously, so
+	// cache never goes stale for files written through this Cache.
+	ModeWriteThrough
+	// ModeWriteBack writes to cache only. Callers that need the write
+	// to reach backing are responsible for arranging that themselves.
+	ModeWriteBack
+)
+
+type entry struct {
+	cachedAt time.Time
+	size     int64
+	modTime  time.Time
+}
+
+// Cache is a billy.Filesystem that promotes reads from a slow backing
+// filesystem into a fast cache filesystem on first Open/Stat, and serves
+// later reads straight from cache until the per-entry TTL expires.
+type Cache struct {
+	backing billy.Filesystem
+	cache   billy.Filesystem
+	ttl     time.Duration
+	mode    CacheMode
+
+	mu      sync.Mutex
+	entries map[string]entry
+}
+
+// New returns a Cache reading through cache, backed by backing. A cached
+// entry is considered fresh for ttl; a ttl of zero means an entry, once
+// promoted, is never re-checked against backing on its own (it is still
+// invalidated by Remove/Rename made through the Cache). Writes are
+// handled according to mode.
+func New(backing, cache billy.Filesystem, ttl time.Duration, mode CacheMode) billy.Filesystem {
+	return &Cache{
+		backing: backing,
+		cache:   cache,
+		ttl:     ttl,
+		mode:    mode,
+		entries: make(map[string]entry),
+	}
+}
+
+func (c *Cache) fresh(filename string) bool {
+	c.mu.Lock()
+	e, ok := c.entries[filename]
+	c.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	return c.ttl <= 0 || time.Since(e.cachedAt) < c.ttl
+}
+
+func (c *Cache) remember(filename string, fi os.FileInfo) {
+	c.mu.Lock()
+	c.entries[filename] = entry{cachedAt: time.Now(), size: fi.Size(), modTime: fi.ModTime()}
+	c.mu.Unlock()
+}
+
+func (c *Cache) forget(filename string) {
+	c.mu.Lock()
+	delete(c.entries, filename)
+	c.mu.Unlock()
+}
+
+// promote ensures filename is present and up to date in cache, copying
+// it in from backing if it is missing, stale per TTL, or its size/mtime
+// no longer matches what was last cached.
+func (c *Cache) promote(filename string) error {
+	if c.fresh(filename) {
+		return nil
+	}
+
+	backingFi, err := c.backing.Stat(filename)
+	if err != nil {
+		// A ModeWriteBack write never reaches backing, so a file that
+		// exists only in cache is expected here, not an error: trust
+		// the cache copy rather than failing the read.
+		if os.IsNotExist(err) {
+			if cacheFi, cacheErr := c.cache.Stat(filename); cacheErr == nil {
+				c.remember(filename, cacheFi)
+				return nil
+			}
+		}
+
+		return err
+	}
+
+	if c.stale(filename, backingFi) {
+		if err := c.copyIn(filename); err != nil {
+			return err
+		}
+	}
+
+	c.remember(filename, backingFi)
+	return nil
+}
+
+// stale reports whether backingFi's size/mtime no longer match what was
+// recorded for filename the last time it was promoted. It deliberately
+// compares against the remembered entry rather than re-statting cache:
+// copyIn writes the cache copy with a fresh, copy-time mtime, so a
+// cache.Stat comparison would never agree with backing's mtime even when
+// nothing has changed.
+func (c *Cache) stale(filename string, backingFi os.FileInfo) bool {
+	c.mu.Lock()
+	e, ok := c.entries[filename]
+	c.mu.Unlock()
+
+	if !ok {
+		return true
+	}
+
+	return e.size != backingFi.Size() || !e.modTime.Equal(backingFi.ModTime())
+}
+
+func (c *Cache) copyIn(filename string) error {
+	src, err := c.backing.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err := c.cache.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+
+	dst, err := c.cache.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+func (c *Cache) Create(filename string) (billy.File, error) {
+	return c.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+func (c *Cache) Open(filename string) (billy.File, error) {
+	return c.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+func (c *Cache) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		if err := c.promote(filename); err != nil {
+			return nil, err
+		}
+
+		return c.cache.OpenFile(filename, flag, perm)
+	}
+
+	switch c.mode {
+	case ModeWriteThrough:
+		bf, err := c.backing.OpenFile(filename, flag, perm)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := c.cache.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			bf.Close()
+			return nil, err
+		}
+
+		cf, err := c.cache.OpenFile(filename, flag, perm)
+		if err != nil {
+			bf.Close()
+			return nil, err
+		}
+
+		c.forget(filename)
+		return &tee{backing: bf, cache: cf, fs: c, filename: filename}, nil
+
+	case ModeWriteBack:
+		c.forget(filename)
+		return c.cache.OpenFile(filename, flag, perm)
+
+	default: // ModePassthrough
+		c.forget(filename)
+		return c.backing.OpenFile(filename, flag, perm)
+	}
+}
+
+// tee is the billy.File returned for a write-through open: writes and
+// seeks apply to both backing and cache so the two stay byte-identical.
+type tee struct {
+	backing billy.File
+	cache   billy.File
+	fs      *Cache
+
+	filename string
+}
+
+func (t *tee) Name() string { return t.backing.Name() }
+
+func (t *tee) Write(p []byte) (int, error) {
+	n, err := t.backing.Write(p)
+	if err != nil {
+		return n, err
+	}
+
+	if _, err := t.cache.Write(p[:n]); err != nil {
+		return n, err
+	}
+
+	return n, nil
+}
+
+func (t *tee) Read(p []byte) (int, error) { return t.backing.Read(p) }
+
+func (t *tee) ReadAt(p []byte, off int64) (int, error) { return t.backing.ReadAt(p, off) }
+
+func (t *tee) Seek(offset int64, whence int) (int64, error) {
+	if _, err := t.cache.Seek(offset, whence); err != nil {
+		return 0, err
+	}
+
+	return t.backing.Seek(offset, whence)
+}
+
+func (t *tee) Truncate(size int64) error {
+	if err := t.backing.Truncate(size); err != nil {
+		return err
+	}
+
+	return t.cache.Truncate(size)
+}
+
+func (t *tee) Lock() error   { return t.backing.Lock() }
+func (t *tee) Unlock() error { return t.backing.Unlock() }
+
+func (t *tee) Close() error {
+	backingErr := t.backing.Close()
+	cacheErr := t.cache.Close()
+
+	if fi, err := t.fs.backing.Stat(t.filename); err == nil {
+		t.fs.remember(t.filename, fi)
+	}
+
+	if backingErr != nil {
+		return backingErr
+	}
+
+	return cacheErr
+}
+
+func (c *Cache) Stat(filename string) (os.FileInfo, error) {
+	if c.fresh(filename) {
+		if fi, err := c.cache.Stat(filename); err == nil {
+			return fi, nil
+		}
+	}
+
+	fi, err := c.backing.Stat(filename)
+	if err != nil {
+		// See promote: a ModeWriteBack write only ever lands in cache.
+		if os.IsNotExist(err) {
+			if cacheFi, cacheErr := c.cache.Stat(filename); cacheErr == nil {
+				c.remember(filename, cacheFi)
+				return cacheFi, nil
+			}
+		}
+
+		c.forget(filename)
+		return nil, err
+	}
+
+	if !fi.IsDir() {
+		if err := c.promote(filename); err != nil {
+			return nil, err
+		}
+
+		return fi, nil
+	}
+
+	c.remember(filename, fi)
+	return fi, nil
+}
+
+func (c *Cache) Lstat(filename string) (os.FileInfo, error) {
+	if l, ok := c.backing.(interface {
+		Lstat(string) (os.FileInfo, error)
+	}); ok {
+		return l.Lstat(filename)
+	}
+
+	return c.backing.Stat(filename)
+}
+
+func (c *Cache) ReadDir(path string) ([]os.FileInfo, error) {
+	return c.backing.ReadDir(path)
+}
+
+func (c *Cache) MkdirAll(filename string, perm os.FileMode) error {
+	if err := c.backing.MkdirAll(filename, perm); err != nil {
+		return err
+	}
+
+	return c.cache.MkdirAll(filename, perm)
+}
+
+func (c *Cache) Rename(from, to string) error {
+	c.forget(from)
+	c.forget(to)
+
+	if err := c.backing.Rename(from, to); err != nil {
+		return err
+	}
+
+	if err := c.cache.Rename(from, to); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (c *Cache) Remove(filename string) error {
+	c.forget(filename)
+
+	if err := c.backing.Remove(filename); err != nil {
+		return err
+	}
+
+	if err := c.cache.Remove(filename); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (c *Cache) Join(elem ...string) string {
+	return c.backing.Join(elem...)
+}
+
+func (c *Cache) Root() string {
+	return c.backing.Root()
+}
+
+func (c *Cache) TempFile(dir, prefix string) (billy.File, error) {
+	tf, ok := c.backing.(billy.TempFile)
+	if !ok {
+		return nil, billy.ErrNotSupported
+	}
+
+	return tf.TempFile(dir, prefix)
+}
+
+func (c *Cache) Link(oldname, newname string) error {
+	l, ok := c.backing.(billy.Link)
+	if !ok {
+		return billy.ErrNotSupported
+	}
+
+	c.forget(newname)
+	return l.Link(oldname, newname)
+}
+
+func (c *Cache) Symlink(target, link string) error {
+	s, ok := c.backing.(billy.Symlink)
+	if !ok {
+		return billy.ErrNotSupported
+	}
+
+	c.forget(link)
+	return s.Symlink(target, link)
+}
+
+func (c *Cache) Readlink(link string) (string, error) {
+	s, ok := c.backing.(billy.Symlink)
+	if !ok {
+		return "", billy.ErrNotSupported
+	}
+
+	return s.Readlink(link)
+}
+
+func (c *Cache) Chroot(path string) (billy.Filesystem, error) {
+	backingCh, ok := c.backing.(billy.Chroot)
+	if !ok {
+		return nil, billy.ErrNotSupported
+	}
+
+	cacheCh, ok := c.cache.(billy.Chroot)
+	if !ok {
+		return nil, billy.ErrNotSupported
+	}
+
+	backing, err := backingCh.Chroot(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cache, err := cacheCh.Chroot(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return New(backing, cache, c.ttl, c.mode), nil
+}
+
+// Capabilities implements billy.Capable, advertising exactly what
+// backing supports: cache is purely an acceleration layer over it.
+func (c *Cache) Capabilities() billy.Capability {
+	return billy.Capabilities(c.backing)
+}
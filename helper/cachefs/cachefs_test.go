@@ -0,0 +1,103 @@
+package cachefs_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/helper/cachefs"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type CacheSuite struct{}
+
+var _ = Suite(&CacheSuite{})
+
+func (s *CacheSuite) TestReadPromotesIntoCache(c *C) {
+	backing := memfs.New()
+	c.Assert(util.WriteFile(backing, "file", []byte("hello"), 0644), IsNil)
+
+	cache := memfs.New()
+	fs := cachefs.New(backing, cache, time.Hour, cachefs.ModePassthrough)
+
+	content, err := util.ReadFile(fs, "file")
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "hello")
+
+	cached, err := util.ReadFile(cache, "file")
+	c.Assert(err, IsNil)
+	c.Assert(string(cached), Equals, "hello")
+}
+
+func (s *CacheSuite) TestWriteThroughUpdatesBoth(c *C) {
+	backing := memfs.New()
+	cache := memfs.New()
+	fs := cachefs.New(backing, cache, time.Hour, cachefs.ModeWriteThrough)
+
+	err := util.WriteFile(fs, "file", []byte("hello"), 0644)
+	c.Assert(err, IsNil)
+
+	content, err := util.ReadFile(backing, "file")
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "hello")
+
+	content, err = util.ReadFile(cache, "file")
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "hello")
+}
+
+func (s *CacheSuite) TestWriteBackOnlyTouchesCache(c *C) {
+	backing := memfs.New()
+	cache := memfs.New()
+	fs := cachefs.New(backing, cache, time.Hour, cachefs.ModeWriteBack)
+
+	err := util.WriteFile(fs, "file", []byte("hello"), 0644)
+	c.Assert(err, IsNil)
+
+	_, err = backing.Stat("file")
+	c.Assert(err, Not(IsNil))
+
+	content, err := util.ReadFile(cache, "file")
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "hello")
+}
+
+func (s *CacheSuite) TestWriteBackIsReadableThroughWrapper(c *C) {
+	backing := memfs.New()
+	cache := memfs.New()
+	fs := cachefs.New(backing, cache, time.Hour, cachefs.ModeWriteBack)
+
+	err := util.WriteFile(fs, "file", []byte("hello"), 0644)
+	c.Assert(err, IsNil)
+
+	content, err := util.ReadFile(fs, "file")
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "hello")
+
+	fi, err := fs.Stat("file")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size(), Equals, int64(len("hello")))
+}
+
+func (s *CacheSuite) TestTTLExpiryPicksUpBackingChange(c *C) {
+	backing := memfs.New()
+	c.Assert(util.WriteFile(backing, "file", []byte("v1"), 0644), IsNil)
+
+	cache := memfs.New()
+	fs := cachefs.New(backing, cache, time.Millisecond, cachefs.ModePassthrough)
+
+	content, err := util.ReadFile(fs, "file")
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "v1")
+
+	time.Sleep(5 * time.Millisecond)
+	c.Assert(util.WriteFile(backing, "file", []byte("v2-longer"), 0644), IsNil)
+
+	content, err = util.ReadFile(fs, "file")
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "v2-longer")
+}
@@ -0,0 +1,320 @@
+// Package overlayfs implements a copy-on-write billy.Filesystem that
+// composes a read-only base filesystem with a writable overlay, in the
+// spirit of afero's copyOnWriteFs.
+package overlayfs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// whiteoutPrefix marks a name in overlay as deleted, even though it may
+// still be present in base.
+const whiteoutPrefix = ".wh."
+
+// Overlay is a billy.Filesystem that reads from overlay first, falling
+// back to base, while all writes, creates and removes are materialized
+// into overlay. base is never modified.
+type Overlay struct {
+	base    billy.Filesystem
+	overlay billy.Filesystem
+}
+
+// New returns a copy-on-write filesystem backed by base (read-only) and
+// overlay (writable). Mutating a file that only exists in base copies it
+// into overlay first; removing it records a whiteout in overlay so it
+// stays hidden even though base is untouched.
+func New(base, overlay billy.Filesystem) billy.Filesystem {
+	return &Overlay{base: base, overlay: overlay}
+}
+
+func (fs *Overlay) whiteoutPath(filename string) string {
+	dir, name := filepath.Split(filename)
+	return filepath.Join(dir, whiteoutPrefix+name)
+}
+
+// isWhiteout reports whether filename is hidden by a whiteout, either
+// its own or an ancestor directory's. A directory whiteout is opaque:
+// Remove/Rename of a directory marks only the directory's own name, but
+// that must hide every path beneath it too, including ones that only
+// ever existed in base and were never listed in overlay.
+func (fs *Overlay) isWhiteout(filename string) bool {
+	for p := filename; ; {
+		if _, err := fs.overlay.Stat(fs.whiteoutPath(p)); err == nil {
+			return true
+		}
+
+		parent := filepath.Dir(p)
+		if parent == p {
+			return false
+		}
+		p = parent
+	}
+}
+
+func (fs *Overlay) markWhiteout(filename string) error {
+	f, err := fs.overlay.Create(fs.whiteoutPath(filename))
+	if err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+func (fs *Overlay) clearWhiteout(filename string) error {
+	err := fs.overlay.Remove(fs.whiteoutPath(filename))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// copyUp materializes filename into overlay from base, so that a
+// subsequent write only ever touches overlay. The overlay copy is
+// opened with base's own mode, rather than Create's 0666 default, so
+// permission bits survive the copy.
+func (fs *Overlay) copyUp(filename string) error {
+	if _, err := fs.overlay.Stat(filename); err == nil {
+		return nil
+	}
+
+	baseFi, err := fs.base.Stat(filename)
+	if err != nil {
+		return err
+	}
+
+	src, err := fs.base.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := fs.overlay.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, baseFi.Mode())
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, src); err != nil {
+		return err
+	}
+
+	return fs.clearWhiteout(filename)
+}
+
+// copyUpTree is copyUp extended to directories: it materializes path
+// into overlay, recursing into base's children, so that a directory
+// that exists only in base can still be renamed (Rename operates on
+// overlay, which must therefore already have the whole subtree).
+func (fs *Overlay) copyUpTree(path string) error {
+	fi, err := fs.base.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if !fi.IsDir() {
+		return fs.copyUp(path)
+	}
+
+	if _, err := fs.overlay.Stat(path); err != nil {
+		if err := fs.overlay.MkdirAll(path, fi.Mode()); err != nil {
+			return err
+		}
+	}
+
+	entries, err := fs.base.ReadDir(path)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if err := fs.copyUpTree(fs.base.Join(path, e.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (fs *Overlay) Create(filename string) (billy.File, error) {
+	if err := fs.clearWhiteout(filename); err != nil {
+		return nil, err
+	}
+
+	return fs.overlay.Create(filename)
+}
+
+func (fs *Overlay) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+func (fs *Overlay) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if flag&os.O_CREATE == 0 && fs.isWhiteout(filename) {
+		return nil, os.ErrNotExist
+	}
+
+	if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		if _, err := fs.overlay.Stat(filename); os.IsNotExist(err) {
+			// A whiteout means filename (or an ancestor) was just
+			// removed: base's bytes are stale and must not be
+			// resurrected by this create-through.
+			if !fs.isWhiteout(filename) {
+				if _, baseErr := fs.base.Stat(filename); baseErr == nil {
+					if err := fs.copyUp(filename); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+
+		if err := fs.clearWhiteout(filename); err != nil {
+			return nil, err
+		}
+
+		return fs.overlay.OpenFile(filename, flag, perm)
+	}
+
+	if f, err := fs.overlay.OpenFile(filename, flag, perm); err == nil {
+		return f, nil
+	}
+
+	return fs.base.OpenFile(filename, flag, perm)
+}
+
+func (fs *Overlay) Stat(filename string) (os.FileInfo, error) {
+	if fs.isWhiteout(filename) {
+		return nil, os.ErrNotExist
+	}
+
+	if fi, err := fs.overlay.Stat(filename); err == nil {
+		return fi, nil
+	}
+
+	return fs.base.Stat(filename)
+}
+
+func (fs *Overlay) ReadDir(path string) ([]os.FileInfo, error) {
+	if fs.isWhiteout(path) {
+		return nil, os.ErrNotExist
+	}
+
+	seen := make(map[string]bool)
+	whiteouts := make(map[string]bool)
+
+	overlayEntries, err := fs.overlay.ReadDir(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var entries []os.FileInfo
+	for _, fi := range overlayEntries {
+		name := fi.Name()
+		if strings.HasPrefix(name, whiteoutPrefix) {
+			whiteouts[strings.TrimPrefix(name, whiteoutPrefix)] = true
+			continue
+		}
+
+		seen[name] = true
+		entries = append(entries, fi)
+	}
+
+	baseEntries, err := fs.base.ReadDir(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	for _, fi := range baseEntries {
+		name := fi.Name()
+		if seen[name] || whiteouts[name] {
+			continue
+		}
+
+		entries = append(entries, fi)
+	}
+
+	return entries, nil
+}
+
+func (fs *Overlay) MkdirAll(filename string, perm os.FileMode) error {
+	if err := fs.clearWhiteout(filename); err != nil {
+		return err
+	}
+
+	return fs.overlay.MkdirAll(filename, perm)
+}
+
+func (fs *Overlay) TempFile(dir, prefix string) (billy.File, error) {
+	return fs.overlay.TempFile(dir, prefix)
+}
+
+func (fs *Overlay) Rename(from, to string) error {
+	if fi, err := fs.base.Stat(from); err == nil {
+		if fi.IsDir() {
+			if err := fs.copyUpTree(from); err != nil {
+				return err
+			}
+		} else if err := fs.copyUp(from); err != nil {
+			return err
+		}
+	}
+
+	if err := fs.overlay.Rename(from, to); err != nil {
+		return err
+	}
+
+	if err := fs.clearWhiteout(to); err != nil {
+		return err
+	}
+
+	if _, err := fs.base.Stat(from); err == nil {
+		return fs.markWhiteout(from)
+	}
+
+	return nil
+}
+
+func (fs *Overlay) Remove(filename string) error {
+	overlayErr := fs.overlay.Remove(filename)
+	if overlayErr != nil && !os.IsNotExist(overlayErr) {
+		return overlayErr
+	}
+
+	if _, err := fs.base.Stat(filename); err == nil {
+		return fs.markWhiteout(filename)
+	}
+
+	return overlayErr
+}
+
+func (fs *Overlay) Join(elem ...string) string {
+	return fs.overlay.Join(elem...)
+}
+
+func (fs *Overlay) Root() string {
+	return fs.overlay.Root()
+}
+
+func (fs *Overlay) Chroot(path string) (billy.Filesystem, error) {
+	base, err := fs.base.Chroot(path)
+	if err != nil {
+		return nil, err
+	}
+
+	overlay, err := fs.overlay.Chroot(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return New(base, overlay), nil
+}
+
+// Capabilities implements billy.Capable, advertising the intersection of
+// what base and overlay each support.
+func (fs *Overlay) Capabilities() billy.Capability {
+	return billy.Capabilities(fs.base) & billy.Capabilities(fs.overlay)
+}
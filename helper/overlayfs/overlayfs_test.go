@@ -0,0 +1,184 @@
+package overlayfs_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/helper/overlayfs"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type OverlayfsSuite struct {
+	test.BasicSuite
+	test.DirSuite
+	test.ChrootSuite
+}
+
+var _ = Suite(&OverlayfsSuite{})
+
+func (s *OverlayfsSuite) SetUpTest(c *C) {
+	fs := overlayfs.New(memfs.New(), memfs.New())
+
+	s.BasicSuite = test.BasicSuite{FS: fs}
+	s.DirSuite = test.DirSuite{FS: fs}
+	s.ChrootSuite = test.ChrootSuite{FS: fs}
+}
+
+func (s *OverlayfsSuite) TestReadFallsThroughToBase(c *C) {
+	base := memfs.New()
+	err := util.WriteFile(base, "file", []byte("from base"), 0644)
+	c.Assert(err, IsNil)
+
+	fs := overlayfs.New(base, memfs.New())
+
+	content, err := util.ReadFile(fs, "file")
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "from base")
+}
+
+func (s *OverlayfsSuite) TestWriteMaterializesIntoOverlay(c *C) {
+	base := memfs.New()
+	err := util.WriteFile(base, "file", []byte("from base"), 0644)
+	c.Assert(err, IsNil)
+
+	overlay := memfs.New()
+	fs := overlayfs.New(base, overlay)
+
+	err = util.WriteFile(fs, "file", []byte("from overlay"), 0644)
+	c.Assert(err, IsNil)
+
+	content, err := util.ReadFile(base, "file")
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "from base")
+
+	content, err = util.ReadFile(overlay, "file")
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "from overlay")
+}
+
+func (s *OverlayfsSuite) TestRemoveBaseFileIsTombstoned(c *C) {
+	base := memfs.New()
+	err := util.WriteFile(base, "file", []byte("from base"), 0644)
+	c.Assert(err, IsNil)
+
+	fs := overlayfs.New(base, memfs.New())
+
+	err = fs.Remove("file")
+	c.Assert(err, IsNil)
+
+	_, err = fs.Stat("file")
+	c.Assert(err, Equals, os.ErrNotExist)
+
+	_, err = util.ReadFile(base, "file")
+	c.Assert(err, IsNil)
+}
+
+func (s *OverlayfsSuite) TestWriteMaterializesIntoOverlayPreservesMode(c *C) {
+	base := memfs.New()
+	err := util.WriteFile(base, "file", []byte("from base"), 0600)
+	c.Assert(err, IsNil)
+
+	overlay := memfs.New()
+	fs := overlayfs.New(base, overlay)
+
+	err = util.WriteFile(fs, "file", []byte("from overlay"), 0600)
+	c.Assert(err, IsNil)
+
+	fi, err := overlay.Stat("file")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode().Perm(), Equals, os.FileMode(0600))
+}
+
+func (s *OverlayfsSuite) TestRenameDirectoryOnlyInBase(c *C) {
+	base := memfs.New()
+	c.Assert(util.WriteFile(base, "dir/a", []byte("a"), 0644), IsNil)
+	c.Assert(util.WriteFile(base, "dir/b", []byte("b"), 0644), IsNil)
+
+	fs := overlayfs.New(base, memfs.New())
+
+	err := fs.Rename("dir", "renamed")
+	c.Assert(err, IsNil)
+
+	content, err := util.ReadFile(fs, "renamed/a")
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "a")
+
+	content, err = util.ReadFile(fs, "renamed/b")
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "b")
+
+	_, err = fs.Stat("dir")
+	c.Assert(err, Equals, os.ErrNotExist)
+}
+
+func (s *OverlayfsSuite) TestRenamedAwayDirectoryHidesBaseChildren(c *C) {
+	base := memfs.New()
+	c.Assert(util.WriteFile(base, "dir/a", []byte("a"), 0644), IsNil)
+
+	fs := overlayfs.New(base, memfs.New())
+
+	err := fs.Rename("dir", "renamed")
+	c.Assert(err, IsNil)
+
+	// "dir" itself is tombstoned; "dir/a" must be hidden too, even
+	// though it was never itself whiteouted and still exists in base.
+	_, err = fs.Stat("dir/a")
+	c.Assert(err, Equals, os.ErrNotExist)
+
+	_, err = fs.Open("dir/a")
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	_, err = fs.ReadDir("dir")
+	c.Assert(err, Equals, os.ErrNotExist)
+}
+
+func (s *OverlayfsSuite) TestCreateAfterRemoveDoesNotResurrectBaseContent(c *C) {
+	base := memfs.New()
+	c.Assert(util.WriteFile(base, "file", []byte("from base"), 0644), IsNil)
+
+	fs := overlayfs.New(base, memfs.New())
+
+	c.Assert(fs.Remove("file"), IsNil)
+
+	f, err := fs.OpenFile("file", os.O_CREATE|os.O_WRONLY, 0644)
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	content, err := util.ReadFile(fs, "file")
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "")
+}
+
+func (s *OverlayfsSuite) TestReadDirMergesAndDedups(c *C) {
+	base := memfs.New()
+	c.Assert(util.WriteFile(base, "dir/a", nil, 0644), IsNil)
+	c.Assert(util.WriteFile(base, "dir/b", nil, 0644), IsNil)
+
+	overlay := memfs.New()
+	c.Assert(util.WriteFile(overlay, "dir/b", []byte("new"), 0644), IsNil)
+	c.Assert(util.WriteFile(overlay, "dir/c", nil, 0644), IsNil)
+
+	fs := overlayfs.New(base, overlay)
+
+	err := fs.Remove("dir/a")
+	c.Assert(err, IsNil)
+
+	fis, err := fs.ReadDir("dir")
+	c.Assert(err, IsNil)
+
+	names := make(map[string]bool)
+	for _, fi := range fis {
+		names[fi.Name()] = true
+	}
+
+	c.Assert(names["a"], Equals, false)
+	c.Assert(names["b"], Equals, true)
+	c.Assert(names["c"], Equals, true)
+	c.Assert(len(fis), Equals, 2)
+}
@@ -0,0 +1,311 @@
+package memfs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// fileNode is the data shared by every name that points at the same
+// underlying content: a regular file's bytes, a directory's mode, or a
+// symlink's target. Hard links (see storage.Link) share a *fileNode;
+// symlinks never do, since the target is per-name data.
+type fileNode struct {
+	content *content
+	mode    os.FileMode
+	symlink string // target path, only meaningful when mode has ModeSymlink set
+
+	modTime time.Time
+}
+
+// file is a name bound to a fileNode, together with the open-handle state
+// (position, flag) for a particular Open/Create call.
+type file struct {
+	name string
+	*fileNode
+
+	position int64
+	flag     int
+	closed   bool
+}
+
+func (f *file) Duplicate(filename string, mode os.FileMode, flag int) *file {
+	new := &file{
+		name:     filename,
+		fileNode: f.fileNode,
+		flag:     flag,
+	}
+
+	if flag&os.O_TRUNC != 0 {
+		new.content.Truncate()
+	}
+
+	return new
+}
+
+func (f *file) Name() string {
+	return f.name
+}
+
+func (f *file) Read(b []byte) (int, error) {
+	n, err := f.ReadAt(b, f.position)
+	f.position += int64(n)
+
+	if err == io.EOF && n != 0 {
+		err = nil
+	}
+
+	return n, err
+}
+
+func (f *file) ReadAt(b []byte, off int64) (int, error) {
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+
+	return f.content.ReadAt(b, off)
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+
+	switch whence {
+	case io.SeekCurrent:
+		f.position += offset
+	case io.SeekStart:
+		f.position = offset
+	case io.SeekEnd:
+		f.position = int64(len(f.content.bytes)) + offset
+	}
+
+	return f.position, nil
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+
+	n, err := f.content.WriteAt(p, f.position)
+	f.position += int64(n)
+
+	return n, err
+}
+
+func (f *file) Close() error {
+	if f.closed {
+		return os.ErrClosed
+	}
+
+	f.closed = true
+	return nil
+}
+
+func (f *file) Truncate(size int64) error {
+	if size < int64(len(f.content.bytes)) {
+		f.content.bytes = f.content.bytes[:size]
+	} else if more := int(size) - len(f.content.bytes); more > 0 {
+		f.content.bytes = append(f.content.bytes, make([]byte, more)...)
+	}
+
+	return nil
+}
+
+func (f *file) Lock() error   { return nil }
+func (f *file) Unlock() error { return nil }
+
+func (c *content) Truncate() {
+	c.bytes = make([]byte, 0)
+}
+
+func (c *content) Len() int {
+	return len(c.bytes)
+}
+
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func newFileInfo(f *file) *fileInfo {
+	return &fileInfo{
+		name:    f.name,
+		size:    int64(f.content.Len()),
+		mode:    f.mode,
+		modTime: f.modTime,
+	}
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) Mode() os.FileMode  { return fi.mode }
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *fileInfo) Sys() interface{}   { return nil }
+
+type byName []*file
+
+func (a byName) Len() int           { return len(a) }
+func (a byName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byName) Less(i, j int) bool { return a[i].name < a[j].name }
+
+// Memory a very convenient filesystem based on memory files
+type Memory struct {
+	s *storage
+}
+
+// New returns a new Memory filesystem.
+func New() billy.Filesystem {
+	fs := &Memory{s: newStorage()}
+	return chroot.New(fs, string(filepath.Separator))
+}
+
+func (fs *Memory) Join(elem ...string) string {
+	return filepath.Join(elem...)
+}
+
+func (fs *Memory) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+func (fs *Memory) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+func (fs *Memory) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if flag&os.O_CREATE != 0 {
+		if _, err := fs.s.New(filepath.Dir(filename), os.ModePerm|os.ModeDir, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	f, has := fs.s.Get(filename)
+	if has && isSymlink(f.mode) {
+		target, err := fs.s.resolve(filename)
+		if err != nil {
+			return nil, &os.PathError{Op: "open", Path: filename, Err: err}
+		}
+		f = target
+	}
+
+	if !has {
+		if flag&os.O_CREATE == 0 {
+			return nil, os.ErrNotExist
+		}
+
+		var err error
+		f, err = fs.s.New(filename, perm, flag)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0 {
+			return nil, os.ErrExist
+		}
+
+		if f.mode.IsDir() {
+			return nil, fmt.Errorf("cannot open directory: %s", filename)
+		}
+
+		f = f.Duplicate(filename, perm, flag)
+	}
+
+	if flag&os.O_APPEND != 0 {
+		f.position = int64(f.content.Len())
+	}
+
+	if flag&os.O_TRUNC != 0 {
+		f.content.Truncate()
+	}
+
+	return f, nil
+}
+
+func (fs *Memory) Stat(filename string) (os.FileInfo, error) {
+	f, err := fs.s.resolve(filename)
+	if err != nil {
+		return nil, &os.PathError{Op: "stat", Path: filename, Err: err}
+	}
+
+	return newFileInfo(&file{name: filepath.Base(filename), fileNode: f.fileNode}), nil
+}
+
+func (fs *Memory) Lstat(filename string) (os.FileInfo, error) {
+	f, has := fs.s.Get(filename)
+	if !has {
+		return nil, &os.PathError{Op: "lstat", Path: filename, Err: os.ErrNotExist}
+	}
+
+	return newFileInfo(f), nil
+}
+
+func (fs *Memory) Symlink(target, link string) error {
+	return fs.s.Symlink(target, link)
+}
+
+func (fs *Memory) Readlink(link string) (string, error) {
+	return fs.s.Readlink(link)
+}
+
+func (fs *Memory) ReadDir(path string) ([]os.FileInfo, error) {
+	if f, has := fs.s.Get(path); has && isSymlink(f.mode) {
+		real, _, err := fs.s.resolvePath(path)
+		if err != nil {
+			return nil, err
+		}
+
+		path = real
+	}
+
+	entries := fs.s.Children(path)
+	sort.Sort(byName(entries))
+
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, f := range entries {
+		infos = append(infos, newFileInfo(f))
+	}
+
+	return infos, nil
+}
+
+func (fs *Memory) MkdirAll(path string, perm os.FileMode) error {
+	_, err := fs.s.New(path, perm.Perm()|os.ModeDir, 0)
+	return err
+}
+
+func (fs *Memory) TempFile(dir, prefix string) (billy.File, error) {
+	return util.TempFile(fs, dir, prefix)
+}
+
+func (fs *Memory) Rename(from, to string) error {
+	return fs.s.Rename(from, to)
+}
+
+func (fs *Memory) Remove(filename string) error {
+	return fs.s.Remove(filename)
+}
+
+func (fs *Memory) Link(oldname, newname string) error {
+	return fs.s.Link(oldname, newname)
+}
+
+func (fs *Memory) Root() string {
+	return string(filepath.Separator)
+}
+
+func (fs *Memory) Capabilities() billy.Capability {
+	return billy.WriteCapability | billy.ReadCapability |
+		billy.ReadAndWriteCapability | billy.SeekCapability |
+		billy.TruncateCapability | billy.LockCapability | billy.SymlinkCapability
+}
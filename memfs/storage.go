@@ -117,6 +117,89 @@ func prepareFile(path string, n *fileNode) *file {
 	}
 }
 
+// maxSymlinkHops bounds the number of indirections resolve will follow
+// before giving up, mirroring the ELOOP guard the os package relies on.
+const maxSymlinkHops = 40
+
+func (s *storage) Symlink(target, link string) error {
+	target = filepath.FromSlash(target)
+	link = clean(link)
+
+	if s.Has(link) {
+		return &os.LinkError{Op: "symlink", Old: target, New: link, Err: os.ErrExist}
+	}
+
+	linkBase := clean(filepath.Dir(link))
+	d, ok := s.Get(linkBase)
+	if !ok || !d.mode.IsDir() {
+		return &os.LinkError{Op: "symlink", Old: target, New: link, Err: os.ErrNotExist}
+	}
+
+	n := &fileNode{
+		content: &content{},
+		mode:    os.ModeSymlink | os.ModePerm,
+		symlink: target,
+	}
+
+	s.files[link] = n
+	s.createParent(link, os.ModePerm, n)
+	return nil
+}
+
+func (s *storage) Readlink(link string) (string, error) {
+	link = clean(link)
+
+	f, ok := s.Get(link)
+	if !ok {
+		return "", os.ErrNotExist
+	}
+
+	if !isSymlink(f.mode) {
+		return "", &os.PathError{Op: "readlink", Path: link, Err: fmt.Errorf("invalid argument")}
+	}
+
+	return f.symlink, nil
+}
+
+// resolve follows the chain of symlinks starting at path, if any, and
+// returns the first node that isn't itself a symlink.
+func (s *storage) resolve(path string) (*file, error) {
+	_, f, err := s.resolvePath(path)
+	return f, err
+}
+
+// resolvePath is resolve, but also returns the real (symlink-free) path
+// the chain bottoms out at, since a resolved node's own name is only its
+// base name (see prepareFile) and can't be used to address it again
+// (e.g. to list a resolved directory's children).
+func (s *storage) resolvePath(path string) (string, *file, error) {
+	path = clean(path)
+
+	for i := 0; i < maxSymlinkHops; i++ {
+		f, ok := s.Get(path)
+		if !ok {
+			return "", nil, os.ErrNotExist
+		}
+
+		if !isSymlink(f.mode) {
+			return path, f, nil
+		}
+
+		target := f.symlink
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+
+		path = clean(target)
+	}
+
+	return "", nil, fmt.Errorf("too many levels of symbolic links: %q", path)
+}
+
+func isSymlink(m os.FileMode) bool {
+	return m&os.ModeSymlink != 0
+}
+
 func (s *storage) Link(target, link string) error {
 	target = clean(target)
 	link = clean(link)
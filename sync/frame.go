@@ -0,0 +1,62 @@
+package sync
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// msgType identifies the kind of frame on the wire. The protocol is a
+// flat stream of frames: one STAT per entry, zero or more DATA frames
+// carrying that entry's content, then a final DONE.
+type msgType byte
+
+const (
+	msgStat msgType = iota
+	msgData
+	msgDone
+)
+
+// maxFrame bounds a single frame's payload, guarding Receive against a
+// corrupt or hostile length prefix forcing an unbounded allocation.
+const maxFrame = 64 << 20 // 64MiB
+
+// writeFrame writes a single type-prefixed, length-prefixed frame.
+func writeFrame(w io.Writer, t msgType, payload []byte) error {
+	header := make([]byte, 5)
+	header[0] = byte(t)
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	if len(payload) == 0 {
+		return nil
+	}
+
+	_, err := w.Write(payload)
+	return err
+}
+
+// readFrame reads a single type-prefixed, length-prefixed frame.
+func readFrame(r io.Reader) (msgType, []byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxFrame {
+		return 0, nil, fmt.Errorf("sync: frame of %d bytes exceeds %d byte limit", size, maxFrame)
+	}
+
+	payload := make([]byte, size)
+	if size > 0 {
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	return msgType(header[0]), payload, nil
+}
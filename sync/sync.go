@@ -0,0 +1,288 @@
+// Package sync serializes the contents of a billy.Filesystem to a stream
+// and reconstructs them on the other side into any writable
+// billy.Filesystem, in the spirit of tonistiigi/fsutil's Send/Walk
+// design. It lets callers mirror a subtree (e.g. snapshot a memfs into an
+// osfs, or across a network pipe) without walking manually.
+package sync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// chunkSize bounds the file content carried by a single DATA frame; the
+// frame's payload is that content plus an 8-byte offset prefix.
+const chunkSize = 32 * 1024
+
+// FilterOpt narrows the set of paths Send walks.
+type FilterOpt struct {
+	// IncludePatterns, when non-empty, restricts Send to paths matching
+	// at least one pattern. ExcludePatterns always wins over it.
+	IncludePatterns []string
+	ExcludePatterns []string
+
+	// AlreadyHave maps a relative path to the SHA256 hex digest the
+	// receiver already has for it. Send skips the DATA frames for any
+	// entry whose current hash matches, so repeated syncs of a mostly
+	// unchanged tree only transfer STAT headers.
+	AlreadyHave map[string]string
+}
+
+// statMsg is the wire representation of a single walked entry.
+type statMsg struct {
+	Path    string      `json:"path"`
+	Mode    os.FileMode `json:"mode"`
+	Size    int64       `json:"size"`
+	ModTime time.Time   `json:"mtime"`
+	Symlink string      `json:"symlink,omitempty"`
+	Hash    string      `json:"hash,omitempty"`
+	HasBody bool        `json:"hasBody,omitempty"`
+}
+
+// Send walks root in fs and writes a framed STAT/DATA/DONE stream
+// describing it to w. Directories and symlinks only ever produce a STAT
+// frame; regular files are followed by one DATA frame per chunk of their
+// content (none for an empty file) unless
+// opt.AlreadyHave already reports the receiver has their current content.
+func Send(ctx context.Context, fs billy.Filesystem, root string, w io.Writer, opt *FilterOpt) error {
+	root = filepath.Clean(root)
+
+	err := util.Walk(fs, root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if rel != "." && !matches(opt, rel) {
+			return nil
+		}
+
+		msg := statMsg{
+			Path:    rel,
+			Mode:    info.Mode(),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := fs.Readlink(path)
+			if err != nil {
+				return err
+			}
+
+			msg.Symlink = target
+		}
+
+		sendBody := false
+		if !info.IsDir() && msg.Symlink == "" {
+			hash, err := hashFile(fs, path)
+			if err != nil {
+				return err
+			}
+
+			msg.Hash = hash
+			sendBody = opt == nil || opt.AlreadyHave[rel] != hash
+			msg.HasBody = sendBody
+		}
+
+		payload, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+
+		if err := writeFrame(w, msgStat, payload); err != nil {
+			return err
+		}
+
+		if !sendBody {
+			return nil
+		}
+
+		f, err := fs.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		return sendData(w, f)
+	})
+	if err != nil {
+		return err
+	}
+
+	return writeFrame(w, msgDone, nil)
+}
+
+func matches(opt *FilterOpt, rel string) bool {
+	if opt == nil {
+		return true
+	}
+
+	for _, p := range opt.ExcludePatterns {
+		if ok, _ := doublestar.Match(p, rel); ok {
+			return false
+		}
+	}
+
+	if len(opt.IncludePatterns) == 0 {
+		return true
+	}
+
+	for _, p := range opt.IncludePatterns {
+		if ok, _ := doublestar.Match(p, rel); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hashFile(fs billy.Filesystem, path string) (string, error) {
+	f, err := fs.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func sendData(w io.Writer, r io.Reader) error {
+	buf := make([]byte, chunkSize)
+	var offset int64
+
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			payload := make([]byte, 8+n)
+			binary.BigEndian.PutUint64(payload[:8], uint64(offset))
+			copy(payload[8:], buf[:n])
+
+			if werr := writeFrame(w, msgData, payload); werr != nil {
+				return werr
+			}
+
+			offset += int64(n)
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Receive reads a stream produced by Send and reconstructs it under root
+// in fs, creating directories, symlinks and files as described by each
+// STAT frame and applying the DATA frames that follow it.
+func Receive(ctx context.Context, fs billy.Filesystem, root string, r io.Reader) error {
+	var current billy.File
+	closeCurrent := func() error {
+		if current == nil {
+			return nil
+		}
+
+		err := current.Close()
+		current = nil
+		return err
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			closeCurrent()
+			return err
+		}
+
+		t, payload, err := readFrame(r)
+		if err != nil {
+			closeCurrent()
+			return err
+		}
+
+		switch t {
+		case msgDone:
+			return closeCurrent()
+
+		case msgStat:
+			if err := closeCurrent(); err != nil {
+				return err
+			}
+
+			var msg statMsg
+			if err := json.Unmarshal(payload, &msg); err != nil {
+				return err
+			}
+
+			full := fs.Join(root, filepath.FromSlash(msg.Path))
+
+			switch {
+			case msg.Mode.IsDir():
+				err = fs.MkdirAll(full, msg.Mode.Perm())
+			case msg.Symlink != "":
+				if err = fs.MkdirAll(filepath.Dir(full), 0755); err == nil {
+					err = fs.Symlink(msg.Symlink, full)
+				}
+			default:
+				if !msg.HasBody {
+					// The sender has nothing new for us (it matched
+					// opt.AlreadyHave): leave whatever is already at
+					// full untouched rather than truncating it.
+					continue
+				}
+
+				if err = fs.MkdirAll(filepath.Dir(full), 0755); err == nil {
+					current, err = fs.Create(full)
+				}
+			}
+
+			if err != nil {
+				return err
+			}
+
+		case msgData:
+			if current == nil || len(payload) < 8 {
+				return fmt.Errorf("sync: DATA frame without a preceding file STAT")
+			}
+
+			offset := int64(binary.BigEndian.Uint64(payload[:8]))
+			if _, err := current.Seek(offset, io.SeekStart); err != nil {
+				return err
+			}
+
+			if _, err := current.Write(payload[8:]); err != nil {
+				return err
+			}
+
+		default:
+			return fmt.Errorf("sync: unknown frame type %d", t)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package sync_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/sync"
+	"github.com/go-git/go-billy/v5/util"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type SyncSuite struct{}
+
+var _ = Suite(&SyncSuite{})
+
+func (s *SyncSuite) TestSendReceiveRoundTrip(c *C) {
+	src := memfs.New()
+	c.Assert(util.WriteFile(src, "a", []byte("hello"), 0644), IsNil)
+	c.Assert(util.WriteFile(src, "dir/b", []byte("world"), 0644), IsNil)
+
+	var buf bytes.Buffer
+	err := sync.Send(context.Background(), src, "/", &buf, nil)
+	c.Assert(err, IsNil)
+
+	dst := memfs.New()
+	err = sync.Receive(context.Background(), dst, "/", &buf)
+	c.Assert(err, IsNil)
+
+	content, err := util.ReadFile(dst, "a")
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "hello")
+
+	content, err = util.ReadFile(dst, "dir/b")
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "world")
+}
+
+func (s *SyncSuite) TestSendHonorsExcludePatterns(c *C) {
+	src := memfs.New()
+	c.Assert(util.WriteFile(src, "keep", nil, 0644), IsNil)
+	c.Assert(util.WriteFile(src, "skip.tmp", nil, 0644), IsNil)
+
+	var buf bytes.Buffer
+	opt := &sync.FilterOpt{ExcludePatterns: []string{"*.tmp"}}
+	err := sync.Send(context.Background(), src, "/", &buf, opt)
+	c.Assert(err, IsNil)
+
+	dst := memfs.New()
+	err = sync.Receive(context.Background(), dst, "/", &buf)
+	c.Assert(err, IsNil)
+
+	_, err = dst.Stat("keep")
+	c.Assert(err, IsNil)
+
+	_, err = dst.Stat("skip.tmp")
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *SyncSuite) TestSendSkipsBodyWhenAlreadyHave(c *C) {
+	src := memfs.New()
+	c.Assert(util.WriteFile(src, "a", []byte("hello"), 0644), IsNil)
+
+	sum := sha256.Sum256([]byte("hello"))
+	opt := &sync.FilterOpt{AlreadyHave: map[string]string{"a": hex.EncodeToString(sum[:])}}
+
+	dst := memfs.New()
+	c.Assert(util.WriteFile(dst, "a", []byte("hello, already here"), 0644), IsNil)
+
+	var buf bytes.Buffer
+	c.Assert(sync.Send(context.Background(), src, "/", &buf, opt), IsNil)
+	c.Assert(sync.Receive(context.Background(), dst, "/", &buf), IsNil)
+
+	content, err := util.ReadFile(dst, "a")
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "hello, already here")
+}
@@ -0,0 +1,215 @@
+package test
+
+import (
+	"os"
+	"runtime"
+
+	. "github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+	. "gopkg.in/check.v1"
+)
+
+// SymlinkSuite is a convenient test suite to validate any implementation of
+// billy.Symlink
+type SymlinkSuite struct {
+	FS interface {
+		Basic
+		Dir
+		Symlink
+		Lstat(filename string) (os.FileInfo, error)
+	}
+}
+
+func (s *SymlinkSuite) TestSymlink(c *C) {
+	if runtime.GOOS == "plan9" {
+		c.Skip("skipping on Plan 9; symlinks are not supported")
+	}
+
+	err := util.WriteFile(s.FS, "file", []byte("hello world!"), 0644)
+	c.Assert(err, IsNil)
+
+	err = s.FS.Symlink("file", "link")
+	c.Assert(err, IsNil)
+
+	fi, err := s.FS.Stat("link")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Name(), Equals, "link")
+	c.Assert(fi.Size(), Equals, int64(12))
+}
+
+func (s *SymlinkSuite) TestSymlinkNested(c *C) {
+	if runtime.GOOS == "plan9" {
+		c.Skip("skipping on Plan 9; symlinks are not supported")
+	}
+
+	err := util.WriteFile(s.FS, "file", []byte("hello world!"), 0644)
+	c.Assert(err, IsNil)
+
+	err = s.FS.Symlink("file", "linkA")
+	c.Assert(err, IsNil)
+
+	err = s.FS.Symlink("linkA", "linkB")
+	c.Assert(err, IsNil)
+
+	fi, err := s.FS.Stat("linkB")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size(), Equals, int64(12))
+}
+
+func (s *SymlinkSuite) TestSymlinkDangling(c *C) {
+	if runtime.GOOS == "plan9" {
+		c.Skip("skipping on Plan 9; symlinks are not supported")
+	}
+
+	err := s.FS.Symlink("nonexistent", "link")
+	c.Assert(err, IsNil)
+
+	_, err = s.FS.Stat("link")
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	fi, err := s.FS.Lstat("link")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode()&os.ModeSymlink, Equals, os.ModeSymlink)
+}
+
+func (s *SymlinkSuite) TestSymlinkWithAbsoluteTarget(c *C) {
+	if runtime.GOOS == "plan9" {
+		c.Skip("skipping on Plan 9; symlinks are not supported")
+	}
+
+	err := util.WriteFile(s.FS, "dir/file", []byte("foo"), 0644)
+	c.Assert(err, IsNil)
+
+	err = s.FS.Symlink("/dir/file", "dir/link")
+	c.Assert(err, IsNil)
+
+	target, err := s.FS.Readlink("dir/link")
+	c.Assert(err, IsNil)
+	c.Assert(target, Equals, "/dir/file")
+
+	fi, err := s.FS.Stat("dir/link")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size(), Equals, int64(3))
+}
+
+func (s *SymlinkSuite) TestSymlinkWithRelativeTarget(c *C) {
+	if runtime.GOOS == "plan9" {
+		c.Skip("skipping on Plan 9; symlinks are not supported")
+	}
+
+	err := util.WriteFile(s.FS, "dir/file", []byte("foo"), 0644)
+	c.Assert(err, IsNil)
+
+	err = s.FS.Symlink("file", "dir/link")
+	c.Assert(err, IsNil)
+
+	fi, err := s.FS.Stat("dir/link")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size(), Equals, int64(3))
+}
+
+func (s *SymlinkSuite) TestSymlinkCycle(c *C) {
+	if runtime.GOOS == "plan9" {
+		c.Skip("skipping on Plan 9; symlinks are not supported")
+	}
+
+	err := s.FS.Symlink("linkB", "linkA")
+	c.Assert(err, IsNil)
+
+	err = s.FS.Symlink("linkA", "linkB")
+	c.Assert(err, IsNil)
+
+	_, err = s.FS.Stat("linkA")
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *SymlinkSuite) TestLstatOnSymlink(c *C) {
+	if runtime.GOOS == "plan9" {
+		c.Skip("skipping on Plan 9; symlinks are not supported")
+	}
+
+	err := util.WriteFile(s.FS, "file", []byte("foo"), 0644)
+	c.Assert(err, IsNil)
+
+	err = s.FS.Symlink("file", "link")
+	c.Assert(err, IsNil)
+
+	fi, err := s.FS.Lstat("link")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Name(), Equals, "link")
+	c.Assert(fi.Mode()&os.ModeSymlink, Equals, os.ModeSymlink)
+
+	fi, err = s.FS.Stat("link")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode()&os.ModeSymlink, Equals, os.FileMode(0))
+}
+
+func (s *SymlinkSuite) TestSymlinkWithExistingLink(c *C) {
+	if runtime.GOOS == "plan9" {
+		c.Skip("skipping on Plan 9; symlinks are not supported")
+	}
+
+	err := util.WriteFile(s.FS, "link", nil, 0644)
+	c.Assert(err, IsNil)
+
+	err = s.FS.Symlink("file", "link")
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *SymlinkSuite) TestRenameWithSymlink(c *C) {
+	if runtime.GOOS == "plan9" {
+		c.Skip("skipping on Plan 9; symlinks are not supported")
+	}
+
+	err := util.WriteFile(s.FS, "dir/file", []byte("foo"), 0644)
+	c.Assert(err, IsNil)
+
+	err = s.FS.Symlink("dir/file", "link")
+	c.Assert(err, IsNil)
+
+	err = s.FS.Rename("link", "newlink")
+	c.Assert(err, IsNil)
+
+	target, err := s.FS.Readlink("newlink")
+	c.Assert(err, IsNil)
+	c.Assert(target, Equals, "dir/file")
+}
+
+func (s *SymlinkSuite) TestReadDirThroughSymlinkToNestedDir(c *C) {
+	if runtime.GOOS == "plan9" {
+		c.Skip("skipping on Plan 9; symlinks are not supported")
+	}
+
+	err := util.WriteFile(s.FS, "real/dir/file", []byte("foo"), 0644)
+	c.Assert(err, IsNil)
+
+	err = s.FS.Symlink("real/dir", "link")
+	c.Assert(err, IsNil)
+
+	fis, err := s.FS.ReadDir("link")
+	c.Assert(err, IsNil)
+	c.Assert(len(fis), Equals, 1)
+	c.Assert(fis[0].Name(), Equals, "file")
+}
+
+func (s *SymlinkSuite) TestRenameTargetWithSymlink(c *C) {
+	if runtime.GOOS == "plan9" {
+		c.Skip("skipping on Plan 9; symlinks are not supported")
+	}
+
+	err := util.WriteFile(s.FS, "dir/file", []byte("foo"), 0644)
+	c.Assert(err, IsNil)
+
+	err = s.FS.Symlink("dir/file", "link")
+	c.Assert(err, IsNil)
+
+	err = s.FS.Rename("dir/file", "dir/newfile")
+	c.Assert(err, IsNil)
+
+	_, err = s.FS.Stat("link")
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	fi, err := s.FS.Lstat("link")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode()&os.ModeSymlink, Equals, os.ModeSymlink)
+}
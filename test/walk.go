@@ -0,0 +1,110 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	. "github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+	. "gopkg.in/check.v1"
+)
+
+// WalkSuite is a convenient test suite to validate any implementation of
+// billy's walking helpers against util.WalkFiltered.
+type WalkSuite struct {
+	FS interface {
+		Basic
+		Dir
+		Symlink
+		Lstat(filename string) (os.FileInfo, error)
+	}
+}
+
+func (s *WalkSuite) walk(opt *util.FilterOpt) ([]string, error) {
+	var visited []string
+	err := util.WalkFiltered(s.FS, "/", opt, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		visited = append(visited, path)
+		return nil
+	})
+
+	sort.Strings(visited)
+	return visited, err
+}
+
+func (s *WalkSuite) TestWalkVisitsEverythingWithoutFilters(c *C) {
+	c.Assert(util.WriteFile(s.FS, "a", nil, 0644), IsNil)
+	c.Assert(util.WriteFile(s.FS, "dir/b", nil, 0644), IsNil)
+
+	visited, err := s.walk(nil)
+	c.Assert(err, IsNil)
+	c.Assert(visited, DeepEquals, []string{"/", "/a", "/dir", "/dir/b"})
+}
+
+func (s *WalkSuite) TestWalkExcludePattern(c *C) {
+	c.Assert(util.WriteFile(s.FS, "keep", nil, 0644), IsNil)
+	c.Assert(util.WriteFile(s.FS, "skip.tmp", nil, 0644), IsNil)
+
+	visited, err := s.walk(&util.FilterOpt{ExcludePatterns: []string{"skip.tmp"}})
+	c.Assert(err, IsNil)
+	c.Assert(visited, DeepEquals, []string{"/", "/keep"})
+}
+
+func (s *WalkSuite) TestWalkIncludeAfterExclude(c *C) {
+	c.Assert(util.WriteFile(s.FS, "dir/keep.go", nil, 0644), IsNil)
+	c.Assert(util.WriteFile(s.FS, "dir/skip.txt", nil, 0644), IsNil)
+
+	visited, err := s.walk(&util.FilterOpt{
+		ExcludePatterns: []string{"**"},
+		IncludePatterns: []string{"**/*.go"},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(visited, DeepEquals, []string{"/", "/dir/keep.go"})
+}
+
+func (s *WalkSuite) TestWalkPrunesExcludedDirectory(c *C) {
+	c.Assert(util.WriteFile(s.FS, "ignored/deep/file", nil, 0644), IsNil)
+	c.Assert(util.WriteFile(s.FS, "kept/file", nil, 0644), IsNil)
+
+	var sawDeep bool
+	err := util.WalkFiltered(s.FS, "/", &util.FilterOpt{ExcludePatterns: []string{"ignored"}}, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if path == filepath.Join("/", "ignored", "deep") {
+			sawDeep = true
+		}
+
+		return nil
+	})
+
+	c.Assert(err, IsNil)
+	c.Assert(sawDeep, Equals, false)
+}
+
+func (s *WalkSuite) TestWalkCycleGuard(c *C) {
+	c.Assert(util.WriteFile(s.FS, "dir/file", nil, 0644), IsNil)
+	err := s.FS.Symlink("/dir", "dir/loop")
+	c.Assert(err, IsNil)
+
+	var count int
+	err = util.WalkFiltered(s.FS, "/", &util.FilterOpt{FollowLinks: true}, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		count++
+		if count > 1000 {
+			return os.ErrInvalid
+		}
+
+		return nil
+	})
+
+	c.Assert(err, IsNil)
+}
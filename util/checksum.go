@@ -0,0 +1,140 @@
+package util
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// Checksum computes a deterministic hash over the subtree rooted at
+// path, mirroring buildkit's contenthash design: each entry contributes
+// its path relative to the scanned root, mode bits and (for a symlink)
+// target, and a directory's digest folds in the sorted digests of its
+// children, so any rename, mode change or content change perturbs the
+// top digest. Hashing the relative path, rather than path itself, is
+// what lets two identical subtrees rooted at different paths (e.g. the
+// same content on memfs and osfs at different mounts) produce the same
+// digest.
+//
+// When followLinks is true, symlinks are dereferenced before hashing;
+// otherwise the link's own metadata is hashed and its target is not
+// walked.
+func Checksum(fs billy.Filesystem, path string, followLinks bool) ([]byte, error) {
+	path = filepath.Clean(path)
+	return checksum(fs, path, path, followLinks, make(map[string]bool))
+}
+
+func checksum(fs billy.Filesystem, root, path string, followLinks bool, visiting map[string]bool) ([]byte, error) {
+	if visiting[path] {
+		return nil, fmt.Errorf("util: checksum cycle detected at %q", path)
+	}
+	visiting[path] = true
+	defer delete(visiting, path)
+
+	info, err := lstatOrStat(fs, path, followLinks)
+	if err != nil {
+		return nil, err
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = path
+	}
+	rel = filepath.ToSlash(rel)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "path:%s\nmode:%o\n", rel, info.Mode())
+
+	switch {
+	// lstatOrStat only ever returns a symlink's own FileInfo when
+	// followLinks is false: when it's true, Stat has already resolved
+	// through the chain, so this case hashes the link's target path
+	// instead, and the regular-file/directory cases below hash the
+	// dereferenced content.
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := fs.Readlink(path)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(h, "symlink:%s\n", target)
+
+	case info.IsDir():
+		entries, err := fs.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+
+		names := make([]string, len(entries))
+		for i, e := range entries {
+			names[i] = e.Name()
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			sum, err := checksum(fs, root, fs.Join(path, name), followLinks, visiting)
+			if err != nil {
+				return nil, err
+			}
+
+			h.Write([]byte(name))
+			h.Write(sum)
+		}
+
+	default:
+		f, err := fs.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		if _, err := io.Copy(h, f); err != nil {
+			return nil, err
+		}
+	}
+
+	return h.Sum(nil), nil
+}
+
+func lstatOrStat(fs billy.Filesystem, path string, followLinks bool) (os.FileInfo, error) {
+	if !followLinks {
+		if lstater, ok := fs.(interface {
+			Lstat(string) (os.FileInfo, error)
+		}); ok {
+			return lstater.Lstat(path)
+		}
+	}
+
+	return fs.Stat(path)
+}
+
+// ChecksumWildcard resolves pattern against fs with Glob, sorts the
+// matches lexicographically and folds each match's subtree digest (as
+// computed by Checksum) into a single digest, giving callers a cheap way
+// to detect a change anywhere under a wildcard without diffing
+// byte-for-byte.
+func ChecksumWildcard(fs billy.Filesystem, pattern string, followLinks bool) ([]byte, error) {
+	matches, err := Glob(fs, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(matches)
+
+	h := sha256.New()
+	for _, m := range matches {
+		sum, err := Checksum(fs, m, followLinks)
+		if err != nil {
+			return nil, err
+		}
+
+		h.Write([]byte(m))
+		h.Write(sum)
+	}
+
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,93 @@
+package util_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type ChecksumSuite struct{}
+
+var _ = Suite(&ChecksumSuite{})
+
+func (s *ChecksumSuite) TestChecksumStableForSameContent(c *C) {
+	fsA := memfs.New()
+	c.Assert(util.WriteFile(fsA, "dir/a", []byte("hello"), 0644), IsNil)
+	c.Assert(util.WriteFile(fsA, "dir/b", []byte("world"), 0644), IsNil)
+
+	fsB := memfs.New()
+	c.Assert(util.WriteFile(fsB, "dir/a", []byte("hello"), 0644), IsNil)
+	c.Assert(util.WriteFile(fsB, "dir/b", []byte("world"), 0644), IsNil)
+
+	sumA, err := util.Checksum(fsA, "dir", false)
+	c.Assert(err, IsNil)
+
+	sumB, err := util.Checksum(fsB, "dir", false)
+	c.Assert(err, IsNil)
+
+	c.Assert(bytes.Equal(sumA, sumB), Equals, true)
+}
+
+func (s *ChecksumSuite) TestChecksumStableAcrossDifferentRoots(c *C) {
+	fsA := memfs.New()
+	c.Assert(util.WriteFile(fsA, "one/dir/a", []byte("hello"), 0644), IsNil)
+	c.Assert(util.WriteFile(fsA, "one/dir/b", []byte("world"), 0644), IsNil)
+
+	fsB := memfs.New()
+	c.Assert(util.WriteFile(fsB, "two/deeper/dir/a", []byte("hello"), 0644), IsNil)
+	c.Assert(util.WriteFile(fsB, "two/deeper/dir/b", []byte("world"), 0644), IsNil)
+
+	sumA, err := util.Checksum(fsA, "one/dir", false)
+	c.Assert(err, IsNil)
+
+	sumB, err := util.Checksum(fsB, "two/deeper/dir", false)
+	c.Assert(err, IsNil)
+
+	c.Assert(bytes.Equal(sumA, sumB), Equals, true)
+}
+
+func (s *ChecksumSuite) TestChecksumChangesOnContentChange(c *C) {
+	fs := memfs.New()
+	c.Assert(util.WriteFile(fs, "dir/a", []byte("hello"), 0644), IsNil)
+
+	before, err := util.Checksum(fs, "dir", false)
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(fs, "dir/a", []byte("goodbye"), 0644), IsNil)
+
+	after, err := util.Checksum(fs, "dir", false)
+	c.Assert(err, IsNil)
+
+	c.Assert(bytes.Equal(before, after), Equals, false)
+}
+
+func (s *ChecksumSuite) TestChecksumChangesOnRename(c *C) {
+	fs := memfs.New()
+	c.Assert(util.WriteFile(fs, "dir/a", []byte("hello"), 0644), IsNil)
+
+	before, err := util.Checksum(fs, "dir", false)
+	c.Assert(err, IsNil)
+
+	c.Assert(fs.Rename("dir/a", "dir/b"), IsNil)
+
+	after, err := util.Checksum(fs, "dir", false)
+	c.Assert(err, IsNil)
+
+	c.Assert(bytes.Equal(before, after), Equals, false)
+}
+
+func (s *ChecksumSuite) TestChecksumWildcardFoldsMatches(c *C) {
+	fs := memfs.New()
+	c.Assert(util.WriteFile(fs, "pkg-a/file", []byte("a"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "pkg-b/file", []byte("b"), 0644), IsNil)
+
+	sum, err := util.ChecksumWildcard(fs, "pkg-*", false)
+	c.Assert(err, IsNil)
+	c.Assert(len(sum) > 0, Equals, true)
+}
@@ -0,0 +1,271 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/moby/patternmatcher"
+)
+
+// maxSymlinkHops bounds how many indirections canonicalPath will follow
+// when resolving a symlink's real identity for the cycle guard.
+const maxSymlinkHops = 40
+
+// FilterOpt narrows the set of paths WalkFiltered visits, using
+// moby/patternmatcher semantics (the same patternmatcher tonistiigi/fsutil
+// uses): "**" matches any number of path segments and a pattern may be
+// negated with a leading "!".
+type FilterOpt struct {
+	IncludePatterns []string
+	ExcludePatterns []string
+
+	// FollowLinks makes WalkFiltered descend into symlinked
+	// directories. A cycle guard, keyed by each symlink's fully
+	// resolved target path, prevents following a loop.
+	FollowLinks bool
+}
+
+// WalkFiltered walks the file tree rooted at root, calling fn for every
+// path that survives opt's include/exclude patterns, in lexical order.
+// A directory excluded by a prefix-only pattern (no negations, and no
+// include patterns that could pull something beneath it back in) is
+// pruned without being descended into, so large excluded subtrees are
+// never read.
+func WalkFiltered(fs billy.Filesystem, root string, opt *FilterOpt, fn filepath.WalkFunc) error {
+	exclude, err := compile(opt.excludePatterns())
+	if err != nil {
+		return err
+	}
+
+	include, err := compile(opt.includePatterns())
+	if err != nil {
+		return err
+	}
+
+	w := &filteredWalker{
+		fs:          fs,
+		root:        root,
+		include:     include,
+		exclude:     exclude,
+		followLinks: opt != nil && opt.FollowLinks,
+		visited:     make(map[string]bool),
+	}
+
+	info, err := fs.Lstat(root)
+	if err != nil {
+		return fn(root, nil, err)
+	}
+
+	if err := fn(root, info, nil); err != nil {
+		return err
+	}
+
+	return w.walkChildren(root, info, fn)
+}
+
+func (o *FilterOpt) excludePatterns() []string {
+	if o == nil {
+		return nil
+	}
+	return o.ExcludePatterns
+}
+
+func (o *FilterOpt) includePatterns() []string {
+	if o == nil {
+		return nil
+	}
+	return o.IncludePatterns
+}
+
+func compile(patterns []string) (*patternmatcher.PatternMatcher, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	return patternmatcher.New(patterns)
+}
+
+type filteredWalker struct {
+	fs          billy.Filesystem
+	root        string
+	include     *patternmatcher.PatternMatcher
+	exclude     *patternmatcher.PatternMatcher
+	followLinks bool
+	visited     map[string]bool
+}
+
+func (w *filteredWalker) relPath(path string) string {
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil {
+		rel = path
+	}
+
+	return filepath.ToSlash(rel)
+}
+
+func (w *filteredWalker) included(rel string) bool {
+	// An explicit include set is the sole authority on what passes:
+	// callers combining it with ExcludePatterns (e.g. exclude "**",
+	// include "**/*.go" to select only Go files) expect include to win.
+	if w.include != nil {
+		m, _ := w.include.Matches(rel)
+		return m
+	}
+
+	if w.exclude != nil {
+		if m, _ := w.exclude.Matches(rel); m {
+			return false
+		}
+	}
+
+	return true
+}
+
+// prunable reports whether a directory that didn't match can be skipped
+// entirely. That's only provable when nothing beneath it could ever
+// match again: the exclude set has no negated patterns, and there is no
+// include set that could re-select a descendant.
+func (w *filteredWalker) prunable(rel string) bool {
+	if w.exclude == nil {
+		return false
+	}
+
+	if w.include != nil {
+		return false
+	}
+
+	if w.exclude.Exclusions() {
+		return false
+	}
+
+	m, _ := w.exclude.Matches(rel)
+	return m
+}
+
+func (w *filteredWalker) walkChildren(path string, info os.FileInfo, fn filepath.WalkFunc) error {
+	if !info.IsDir() {
+		return nil
+	}
+
+	entries, err := w.fs.ReadDir(path)
+	if err != nil {
+		return fn(path, info, err)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	for _, e := range entries {
+		childPath := w.fs.Join(path, e.Name())
+
+		childInfo, err := w.lstat(childPath, e)
+		if err != nil {
+			return err
+		}
+
+		rel := w.relPath(childPath)
+		if !w.included(rel) {
+			if childInfo.IsDir() && w.prunable(rel) {
+				continue
+			}
+			if !childInfo.IsDir() {
+				continue
+			}
+		} else if err := fn(childPath, childInfo, nil); err != nil {
+			if err == filepath.SkipDir && childInfo.IsDir() {
+				continue
+			}
+			return err
+		}
+
+		resolved := childInfo
+		recursePath := childPath
+		if childInfo.Mode()&os.ModeSymlink != 0 {
+			if !w.followLinks {
+				continue
+			}
+
+			real := w.canonicalPath(childPath)
+			if w.visited[real] {
+				continue
+			}
+			w.visited[real] = true
+
+			resolved, err = w.fs.Stat(childPath)
+			if err != nil {
+				continue
+			}
+
+			// Continue walking from the resolved, symlink-free path: a
+			// directory reached through two different symlinks (or
+			// through the same symlink twice) is thereby only ever
+			// walked once, and ReadDir sees a real directory rather
+			// than a symlink alias to one.
+			recursePath = real
+		}
+
+		if err := w.walkChildren(recursePath, resolved, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lstat returns a non-following FileInfo for path, preferring the
+// filesystem's Lstat when available so symlinks surface as such.
+func (w *filteredWalker) lstat(path string, fallback os.FileInfo) (os.FileInfo, error) {
+	if l, ok := w.fs.(interface {
+		Lstat(string) (os.FileInfo, error)
+	}); ok {
+		return l.Lstat(path)
+	}
+
+	return fallback, nil
+}
+
+// canonicalPath fully resolves path through any chain of symlinks and
+// returns the resulting path, used as the cycle-guard identity: two
+// symlinks that resolve to the same real directory get the same key.
+// Filesystems that don't implement billy.Symlink can't produce a
+// symlink loop, so path is already canonical for them.
+func (w *filteredWalker) canonicalPath(path string) string {
+	rl, ok := w.fs.(billy.Symlink)
+	if !ok {
+		return path
+	}
+
+	lst, ok := w.fs.(interface {
+		Lstat(string) (os.FileInfo, error)
+	})
+	if !ok {
+		return path
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i < maxSymlinkHops; i++ {
+		if seen[path] {
+			return path
+		}
+		seen[path] = true
+
+		fi, err := lst.Lstat(path)
+		if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+			return path
+		}
+
+		target, err := rl.Readlink(path)
+		if err != nil {
+			return path
+		}
+
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+
+		path = filepath.Clean(target)
+	}
+
+	return path
+}